@@ -0,0 +1,54 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import "flag"
+
+// CloudConfig holds provider-specific configuration for reaching a cluster
+// that tests can't get to purely through the Kubernetes API.
+type CloudConfig struct {
+	// BastionHost is an SSH-reachable host with access to node internal
+	// IPs, used by awsBastionNodeExecutor on providers (e.g. aws) where
+	// the e2e runner can't SSH to nodes directly.
+	BastionHost string
+}
+
+// TestContextType holds the configuration common to all e2e tests, set from
+// command-line flags in init() below.
+type TestContextType struct {
+	// Provider is the name of the cloud provider the cluster under test
+	// runs on (e.g. "gce", "gke", "aws", "vagrant", "vsphere"); see
+	// executorForProvider and providerIs.
+	Provider string
+
+	CloudConfig CloudConfig
+
+	// RebootMaxUnavailable caps how many nodes the Reboot suite disrupts
+	// at once. Zero or negative means no cap: disrupt every node
+	// simultaneously, which is also the default.
+	RebootMaxUnavailable int
+}
+
+var testContext TestContextType
+
+func init() {
+	flag.StringVar(&testContext.Provider, "provider", "", "The name of the Kubernetes provider (gce, gke, aws, vagrant, vsphere, etc.)")
+	flag.StringVar(&testContext.CloudConfig.BastionHost, "bastion-host", "",
+		"Address of a bastion host the e2e runner can reach nodes through, for providers (e.g. aws) where nodes aren't directly reachable")
+	flag.IntVar(&testContext.RebootMaxUnavailable, "reboot-max-unavailable", 0,
+		"Maximum number of nodes the Reboot suite disrupts at once; 0 or negative disrupts every node simultaneously")
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package e2e
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
@@ -37,6 +40,39 @@ var dnsServiceLableSelector = labels.Set{
 	"kubernetes.io/cluster-service": "true",
 }.AsSelector()
 
+// dnsQuery is one lookup the querier pod (test/images/dns-querier) should
+// perform. category groups related queries (e.g. "headless-a", "srv") so a
+// regression in one kube-dns code path is reported distinctly from one in
+// another. proto "tcp" forces "A" lookups over TCP; with "udp" the lookup
+// goes through the querier's system resolver, which picks its own
+// transport. proto is ignored for other types. The JSON tags here must match the
+// query struct the querier binary unmarshals.
+type dnsQuery struct {
+	Category string `json:"category"`
+	Proto    string `json:"proto"`
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Type     string `json:"type"` // "A", "SRV", or "PTR"
+}
+
+// dnsQueryResult is one entry of the querier's JSON report. The JSON tags
+// here must match the result struct the querier binary marshals.
+type dnsQueryResult struct {
+	Category  string   `json:"category"`
+	Proto     string   `json:"proto"`
+	Key       string   `json:"key"`
+	OK        bool     `json:"ok"`
+	Error     string   `json:"error,omitempty"`
+	Answers   []string `json:"answers,omitempty"`
+	LatencyMs float64  `json:"latencyMs"`
+}
+
+// dnsQueryReport is the top-level document the querier writes to
+// /results/summary.json.
+type dnsQueryReport struct {
+	Results []dnsQueryResult `json:"results"`
+}
+
 var _ = Describe("DNS", func() {
 	var c *client.Client
 	// Use this in tests.  They're unique for each test to prevent name collisions.
@@ -50,6 +86,7 @@ var _ = Describe("DNS", func() {
 		Expect(err).NotTo(HaveOccurred())
 		testNamespace = ns.Name
 	})
+
 	It("should provide DNS for the cluster", func() {
 		if providerIs("vagrant") {
 			By("Skipping test which is broken for vagrant (See https://github.com/GoogleCloudPlatform/kubernetes/issues/3580)")
@@ -68,21 +105,149 @@ var _ = Describe("DNS", func() {
 		}
 		expectNoError(waitForPodRunning(c, dnsPods.Items[0].Name))
 
-		// All the names we need to be able to resolve.
-		// TODO: Spin up a separate test service and test that dns works for that service.
+		// All the plain names we need to be able to resolve, independent of
+		// anything this test stands up itself.
 		namesToResolve := []string{
 			"kubernetes-ro.default",
 			"kubernetes-ro.default.cluster.local",
 			"google.com",
 		}
 
-		probeCmd := "for i in `seq 1 600`; do "
+		By("looking up the ClusterIP of the kubernetes-ro service for a PTR check")
+		kubernetesRO, err := c.Services(api.NamespaceDefault).Get("kubernetes-ro")
+		if err != nil {
+			Failf("Failed to get kubernetes-ro service: %v", err)
+		}
+
+		// Stand up a dedicated headless Service backed by a multi-replica RC
+		// so we can exercise the DNS records that only a real, multi-pod
+		// Service produces: per-pod A records, SRV records for named ports,
+		// and (via the RC's pods) more PTR targets.
+		rcName := "dns-test-" + string(util.NewUUID())
+		const replicas = 3
+		podLabels := map[string]string{"dns-test": rcName}
+
+		By(fmt.Sprintf("creating replication controller %s with %d replicas", rcName, replicas))
+		rc := &api.ReplicationController{
+			ObjectMeta: api.ObjectMeta{Name: rcName},
+			Spec: api.ReplicationControllerSpec{
+				Replicas: replicas,
+				Selector: podLabels,
+				Template: &api.PodTemplateSpec{
+					ObjectMeta: api.ObjectMeta{Labels: podLabels},
+					Spec: api.PodSpec{
+						Containers: []api.Container{
+							{
+								Name:  "backend",
+								Image: "gcr.io/google_containers/pause:go",
+								Ports: []api.ContainerPort{
+									{Name: "http", ContainerPort: 80},
+									{Name: "https", ContainerPort: 443},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		if _, err := c.ReplicationControllers(testNamespace).Create(rc); err != nil {
+			Failf("Failed to create replication controller %s: %v", rcName, err)
+		}
+		defer func() {
+			By("deleting the replication controller")
+			defer GinkgoRecover()
+			c.ReplicationControllers(testNamespace).Delete(rcName)
+		}()
+
+		By("creating a headless service in front of the replication controller")
+		headlessSvc := &api.Service{
+			ObjectMeta: api.ObjectMeta{Name: rcName},
+			Spec: api.ServiceSpec{
+				ClusterIP: api.ClusterIPNone,
+				Selector:  podLabels,
+				Ports: []api.ServicePort{
+					{Name: "http", Port: 80},
+					{Name: "https", Port: 443},
+				},
+			},
+		}
+		if _, err := c.Services(testNamespace).Create(headlessSvc); err != nil {
+			Failf("Failed to create headless service %s: %v", rcName, err)
+		}
+		defer func() {
+			By("deleting the headless service")
+			defer GinkgoRecover()
+			c.Services(testNamespace).Delete(rcName)
+		}()
+
+		By("waiting for the replication controller's pods to all be running")
+		if !waitForRCPodsRunning(c, testNamespace, rcName, "", replicas, podReadyAgainTimeout) {
+			Failf("Replication controller %s never reached %d running pods", rcName, replicas)
+		}
+		backendPods, err := c.Pods(testNamespace).List(labels.Set(podLabels).AsSelector(), fields.Everything())
+		if err != nil {
+			Failf("Failed to list pods for %s: %v", rcName, err)
+		}
+
+		headlessName := fmt.Sprintf("%s.%s.svc.cluster.local", rcName, testNamespace)
+		srvNames := []string{
+			fmt.Sprintf("_http._tcp.%s.%s.svc.cluster.local", rcName, testNamespace),
+			fmt.Sprintf("_https._tcp.%s.%s.svc.cluster.local", rcName, testNamespace),
+		}
+		var podARecords []string
+		var podIPs []string
+		ptrIPs := []string{kubernetesRO.Spec.ClusterIP}
+		for _, p := range backendPods.Items {
+			if p.Status.PodIP == "" {
+				continue
+			}
+			podARecords = append(podARecords,
+				fmt.Sprintf("%s.%s.pod.cluster.local", strings.Replace(p.Status.PodIP, ".", "-", -1), testNamespace))
+			podIPs = append(podIPs, p.Status.PodIP)
+			ptrIPs = append(ptrIPs, p.Status.PodIP)
+		}
+
+		// Build the list of queries for the querier pod to perform. Unlike
+		// the old dig-based probe, this scales to hundreds of names without
+		// turning into hundreds of apiserver proxy round-trips: the querier
+		// does every lookup itself and the test reads back one summary.
+		var queries []dnsQuery
 		for _, name := range namesToResolve {
-			// Resolve by TCP and UDP DNS.
-			probeCmd += fmt.Sprintf(`test -n "$(dig +notcp +noall +answer +search %s)" && echo OK > /results/udp@%s;`, name, name)
-			probeCmd += fmt.Sprintf(`test -n "$(dig +tcp +noall +answer +search %s)" && echo OK > /results/tcp@%s;`, name, name)
+			// Check both transports for this category, as the old
+			// dig-based probe did with +notcp/+tcp.
+			for _, proto := range []string{"udp", "tcp"} {
+				queries = append(queries, dnsQuery{
+					Category: "cluster",
+					Proto:    proto,
+					Key:      fmt.Sprintf("%s (%s)", name, proto),
+					Name:     name,
+					Type:     "A",
+				})
+			}
+		}
+		queries = append(queries, dnsQuery{Category: "headless-a", Proto: "udp", Key: headlessName, Name: headlessName, Type: "A"})
+		for _, name := range srvNames {
+			queries = append(queries, dnsQuery{Category: "srv", Proto: "udp", Key: name, Name: name, Type: "SRV"})
+		}
+		for _, name := range podARecords {
+			queries = append(queries, dnsQuery{Category: "pod-a", Proto: "udp", Key: name, Name: name, Type: "A"})
+		}
+		for _, ip := range ptrIPs {
+			queries = append(queries, dnsQuery{Category: "ptr", Proto: "udp", Key: ip, Name: ip, Type: "PTR"})
+		}
+
+		queriesJSON, err := json.Marshal(queries)
+		if err != nil {
+			Failf("Failed to marshal DNS queries: %v", err)
 		}
-		probeCmd += "sleep 1; done"
+
+		// The querier writes /queries.json itself (via this heredoc) and
+		// then repeatedly re-runs the batch, refreshing /results/summary.json,
+		// so the test below can poll a single file until it looks healthy.
+		probeCmd := fmt.Sprintf(`cat <<'EOF' > /queries.json
+%s
+EOF
+for i in $(seq 1 600); do /dns-querier -queries=/queries.json -out=/results/summary.json; sleep 1; done`, queriesJSON)
 
 		// Run a pod which probes DNS and exposes the results by HTTP.
 		By("creating a pod to probe DNS")
@@ -118,7 +283,7 @@ var _ = Describe("DNS", func() {
 					},
 					{
 						Name:    "querier",
-						Image:   "gcr.io/google_containers/dnsutils",
+						Image:   "gcr.io/google_containers/dns-querier:e2e",
 						Command: []string{"sh", "-c", probeCmd},
 						VolumeMounts: []api.VolumeMount{
 							{
@@ -150,37 +315,110 @@ var _ = Describe("DNS", func() {
 			Failf("Failed to get pod %s: %v", pod.Name, err)
 		}
 
-		// Try to find results for each expected name.
-		By("looking for the results for each expected name")
-		var failed []string
+		// Fetch the querier's report with a single proxy GET, rather than
+		// one GET per name/protocol, and check it for per-category
+		// failures so a regression in (say) SRV lookups is reported
+		// distinctly from one in PTR lookups.
+		By("fetching the DNS probe summary")
+		var report dnsQueryReport
+		var failedByCategory map[string][]string
 
 		expectNoError(wait.Poll(time.Second*2, time.Second*60, func() (bool, error) {
-			failed = []string{}
-			for _, name := range namesToResolve {
-				for _, proto := range []string{"udp", "tcp"} {
-					testCase := fmt.Sprintf("%s@%s", proto, name)
-					_, err := c.Get().
-						Prefix("proxy").
-						Resource("pods").
-						Namespace(testNamespace).
-						Name(pod.Name).
-						Suffix("results", testCase).
-						Do().Raw()
-					if err != nil {
-						failed = append(failed, testCase)
-					}
+			raw, err := c.Get().
+				Prefix("proxy").
+				Resource("pods").
+				Namespace(testNamespace).
+				Name(pod.Name).
+				Suffix("results", "summary.json").
+				Do().Raw()
+			if err != nil {
+				Logf("Fetching DNS summary from %s failed: %v", pod.Name, err)
+				return false, nil
+			}
+			if err := json.Unmarshal(raw, &report); err != nil {
+				Logf("Parsing DNS summary from %s failed: %v", pod.Name, err)
+				return false, nil
+			}
+			if len(report.Results) != len(queries) {
+				// The querier hasn't finished a full pass yet.
+				return false, nil
+			}
+
+			failedByCategory = map[string][]string{}
+			for _, res := range report.Results {
+				if !res.OK {
+					failedByCategory[res.Category] = append(failedByCategory[res.Category], res.Key)
+					continue
+				}
+				// The headless service's A record is only really healthy
+				// once it returns every backend pod's IP, not just any one
+				// of them -- a kube-dns regression that dropped all but a
+				// stale pod IP would still pass a plain non-empty check.
+				if res.Category == "headless-a" && !answersIncludeAll(res.Answers, podIPs) {
+					failedByCategory[res.Category] = append(failedByCategory[res.Category],
+						fmt.Sprintf("%s (got %v, want all of %v)", res.Key, res.Answers, podIPs))
 				}
 			}
-			if len(failed) == 0 {
+			if len(failedByCategory) == 0 {
 				return true, nil
 			}
-			Logf("Lookups using %s failed for: %v\n", pod.Name, failed)
+			for category, failed := range failedByCategory {
+				Logf("DNS lookups using %s failed for %s: %v\n", pod.Name, category, failed)
+			}
 			return false, nil
 		}))
-		Expect(len(failed)).To(Equal(0))
+		Expect(len(failedByCategory)).To(Equal(0))
+
+		logLatencyPercentiles(report)
 
 		// TODO: probe from the host, too.
 
 		Logf("DNS probes using %s succeeded\n", pod.Name)
 	})
 })
+
+// logLatencyPercentiles logs the p50 and p99 DNS resolution latency seen in
+// report, both overall and broken out per category, to help spot a
+// resolution-time regression in kube-dns that wouldn't otherwise fail the
+// test.
+func logLatencyPercentiles(report dnsQueryReport) {
+	byCategory := map[string][]float64{}
+	for _, res := range report.Results {
+		byCategory[res.Category] = append(byCategory[res.Category], res.LatencyMs)
+		byCategory["overall"] = append(byCategory["overall"], res.LatencyMs)
+	}
+	for _, category := range []string{"overall", "cluster", "headless-a", "srv", "pod-a", "ptr"} {
+		latencies := byCategory[category]
+		if len(latencies) == 0 {
+			continue
+		}
+		sort.Float64s(latencies)
+		Logf("DNS latency for %s: p50=%.1fms p99=%.1fms (n=%d)",
+			category, percentile(latencies, 50), percentile(latencies, 99), len(latencies))
+	}
+}
+
+// percentile returns the p-th percentile (0-100) of sorted, which must
+// already be sorted in ascending order.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p / 100 * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+// answersIncludeAll returns whether every IP in want appears somewhere in
+// answers, order and duplicates aside.
+func answersIncludeAll(answers, want []string) bool {
+	got := map[string]bool{}
+	for _, a := range answers {
+		got[a] = true
+	}
+	for _, w := range want {
+		if !got[w] {
+			return false
+		}
+	}
+	return true
+}
@@ -18,12 +18,16 @@ package e2e
 
 import (
 	"fmt"
+	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/fields"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/wait"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -52,8 +56,27 @@ const (
 
 	// How long pods have to be "ready" after the reboot.
 	podReadyAgainTimeout = 5 * time.Minute
+
+	// How long a node is allowed to go from "Ready" to "NotReady" after an
+	// unclean shutdown (e.g. a kernel panic) before the test is considered
+	// failed. Shorter than rebootNotReadyTimeout: there's no graceful
+	// kubelet shutdown to wait out first, so the node's heartbeat just
+	// stops outright.
+	kernelPanicNotReadyTimeout = 1 * time.Minute
 )
 
+// disruptors is the set of node disruption strategies exercised by the
+// Reboot suite. Each one takes the node down in a qualitatively different
+// way so that the same node-recovery invariants get validated across clean
+// reboots, kubelet-only outages, network partitions and kernel panics, not
+// just `sudo reboot`.
+var disruptors = []Disruptor{
+	&rebootDisruptor{},
+	&kubeletStopDisruptor{},
+	&networkPartitionDisruptor{},
+	&kernelPanicDisruptor{},
+}
+
 var _ = Describe("Reboot", func() {
 	BeforeEach(func() {
 		var err error
@@ -61,53 +84,445 @@ var _ = Describe("Reboot", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
-	It("should reboot each node and ensure they function upon restart", func() {
-		// This test requires SSH, so the provider check should be identical to
-		// there (the limiting factor is the implementation of util.go's
-		// getSigner(...)).
-		provider := testContext.Provider
-		if !providerIs("gce", "gke") {
-			By(fmt.Sprintf("Skipping reboot test, which is not implemented for %s", provider))
-			return
-		}
+	for i := range disruptors {
+		d := disruptors[i]
+		It(fmt.Sprintf("should reboot each node by %s and ensure they function upon restart", d.Name()), func() {
+			// Pick the transport used to run disruption commands on nodes
+			// for this provider; providers without a dedicated one fall
+			// back to scheduling a privileged pod directly onto the node,
+			// so this no longer needs to skip non-GCE/GKE providers.
+			exec := executorForProvider(testContext.Provider)
+
+			// Get all nodes, and kick off the test on each, honoring the
+			// configured disruption budget. A non-positive value means
+			// "stampede": disrupt every node at once, which is also the
+			// zero-value/default behavior.
+			nodelist, err := c.Nodes().List(labels.Everything(), fields.Everything())
+			if err != nil {
+				Failf("Error getting nodes: %v", err)
+			}
+			maxUnavailable := testContext.RebootMaxUnavailable
+			if maxUnavailable <= 0 {
+				maxUnavailable = len(nodelist.Items)
+			}
+			if !rebootNodesWithConcurrency(c, exec, nodelist.Items, d, maxUnavailable) {
+				Failf("Test failed; at least one node failed to reboot in the time given.")
+			}
+		})
+	}
+
+	It("should support rolling reboot and reschedule evicted pods onto surviving nodes", func() {
+		exec := executorForProvider(testContext.Provider)
 
-		// Get all nodes, and kick off the test on each.
 		nodelist, err := c.Nodes().List(labels.Everything(), fields.Everything())
 		if err != nil {
 			Failf("Error getting nodes: %v", err)
 		}
-		result := make(chan bool, len(nodelist.Items))
-		for _, n := range nodelist.Items {
-			go rebootNode(c, provider, n.ObjectMeta.Name, result)
+		numNodes := len(nodelist.Items)
+		if numNodes < 2 {
+			By("Skipping rolling reboot test, which needs at least 2 nodes to observe rescheduling")
+			return
 		}
 
-		// Wait for all to finish and check the final result.
-		failed := false
-		// TODO(mbforbes): Change to `for range` syntax and remove logging once
-		// we support only Go >= 1.4.
-		for _, n := range nodelist.Items {
-			if !<-result {
-				Failf("Node %s failed reboot test.", n.ObjectMeta.Name)
-				failed = true
-			}
+		rcName := "rolling-reboot-" + string(util.NewUUID())
+		replicas := 2 * numNodes
+		By(fmt.Sprintf("creating replication controller %s with %d replicas", rcName, replicas))
+		rc := &api.ReplicationController{
+			ObjectMeta: api.ObjectMeta{Name: rcName},
+			Spec: api.ReplicationControllerSpec{
+				Replicas: replicas,
+				Selector: map[string]string{"name": rcName},
+				Template: &api.PodTemplateSpec{
+					ObjectMeta: api.ObjectMeta{Labels: map[string]string{"name": rcName}},
+					Spec: api.PodSpec{
+						Containers: []api.Container{
+							{
+								Name:  rcName,
+								Image: "gcr.io/google_containers/pause:go",
+							},
+						},
+					},
+				},
+			},
 		}
-		if failed {
-			Failf("Test failed; at least one node failed to reboot in the time given.")
+		if _, err := c.ReplicationControllers(api.NamespaceDefault).Create(rc); err != nil {
+			Failf("Failed to create replication controller %s: %v", rcName, err)
+		}
+		defer func() {
+			By("deleting the replication controller")
+			defer GinkgoRecover()
+			c.ReplicationControllers(api.NamespaceDefault).Delete(rcName)
+		}()
+
+		By("waiting for the replication controller's pods to all be running")
+		if !waitForRCPodsRunning(c, api.NamespaceDefault, rcName, "", replicas, podReadyAgainTimeout) {
+			Failf("Replication controller %s never reached %d running replicas", rcName, replicas)
+		}
+
+		if !rebootNodesRolling(c, exec, nodelist.Items, api.NamespaceDefault, rcName, replicas) {
+			Failf("Rolling reboot test failed; see above for which node and step.")
 		}
 	})
 })
 
-// rebootNode takes node name on provider through the following steps using c:
+// rebootNodesWithConcurrency disrupts nodes via d, allowing at most
+// maxUnavailable of them to be disrupted (i.e. in the window between Disrupt
+// and becoming Ready again) at the same time. It returns whether every node
+// completed its disruption and recovery successfully.
+func rebootNodesWithConcurrency(c *client.Client, exec NodeExecutor, nodes []api.Node, d Disruptor, maxUnavailable int) bool {
+	if maxUnavailable <= 0 || maxUnavailable > len(nodes) {
+		maxUnavailable = len(nodes)
+	}
+	sem := make(chan struct{}, maxUnavailable)
+	result := make(chan bool, len(nodes))
+	for _, n := range nodes {
+		go func(n api.Node) {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			nodeResult := make(chan bool, 1)
+			rebootNode(c, exec, n.ObjectMeta.Name, d, nodeResult)
+			result <- <-nodeResult
+		}(n)
+	}
+
+	// Wait for all to finish and check the final result.
+	success := true
+	// TODO(mbforbes): Change to `for range` syntax and remove logging once
+	// we support only Go >= 1.4.
+	for _, n := range nodes {
+		if !<-result {
+			Failf("Node %s failed reboot test.", n.ObjectMeta.Name)
+			success = false
+		}
+	}
+	return success
+}
+
+// rebootNodesRolling reboots nodes one at a time via the rebootDisruptor,
+// never allowing more than one to be unavailable simultaneously. Between
+// disrupting a node and waiting for it to come back, it asserts that the RC
+// rcName (in rcNamespace, with desiredReplicas replicas) still has its full
+// complement of pods Running and that enough of them moved off the disrupted
+// node to prove the evicted ones were rescheduled onto surviving nodes,
+// rather than the test simply waiting for the original node to return.
+func rebootNodesRolling(c *client.Client, exec NodeExecutor, nodes []api.Node, rcNamespace, rcName string, desiredReplicas int) bool {
+	d := &rebootDisruptor{}
+	notReadyTimeout, readyAgainTimeout := d.ExpectedRecoveryWindow()
+	success := true
+	for i := range nodes {
+		n := &nodes[i]
+		name := n.ObjectMeta.Name
+
+		By(fmt.Sprintf("rebooting node %s", name))
+		if err := d.Disrupt(exec, c, n); err != nil {
+			Failf("Error rebooting node %s: %v", name, err)
+			success = false
+			continue
+		}
+
+		if !waitForNodeToBeNotReady(c, name, notReadyTimeout) {
+			success = false
+			continue
+		}
+
+		By(fmt.Sprintf("waiting for pods evicted from %s to be rescheduled onto surviving nodes", name))
+		if !waitForRCPodsRunning(c, rcNamespace, rcName, name, desiredReplicas, readyAgainTimeout) {
+			Logf("Pods of %s were not rescheduled off of %s in time", rcName, name)
+			success = false
+		}
+
+		if !waitForNodeToBeReady(c, name, readyAgainTimeout) {
+			success = false
+		}
+	}
+	return success
+}
+
+// Disruptor knows how to take a single node down in some fashion, and how
+// long the Reboot suite should expect the node to take going "NotReady" and
+// then "Ready" again afterwards. Implementations range from a clean reboot
+// to ones that never touch the kernel's shutdown path at all (e.g. killing
+// just the kubelet, or partitioning the node from the apiserver). They issue
+// their disruption command through a NodeExecutor rather than over SSH
+// directly, so the same Disruptor runs on any provider.
+type Disruptor interface {
+	// Name identifies the disruption strategy in test output.
+	Name() string
+
+	// Disrupt performs the disruption against node via exec. It returns an
+	// error if the disruption could not be initiated; it does not wait for
+	// the node to actually go unready.
+	Disrupt(exec NodeExecutor, c *client.Client, node *api.Node) error
+
+	// ExpectedRecoveryWindow returns how long the node is allowed to take
+	// going from "Ready" to "NotReady" (notReady), and from "NotReady" back
+	// to "Ready" (ready), after Disrupt is called.
+	ExpectedRecoveryWindow() (notReady, ready time.Duration)
+}
+
+// rebootDisruptor issues a clean `sudo reboot`, the original (and mildest)
+// disruption this suite exercised.
+type rebootDisruptor struct{}
+
+func (*rebootDisruptor) Name() string { return "reboot" }
+
+func (*rebootDisruptor) Disrupt(exec NodeExecutor, c *client.Client, node *api.Node) error {
+	return exec.Execute(c, node, "sudo reboot")
+}
+
+func (*rebootDisruptor) ExpectedRecoveryWindow() (time.Duration, time.Duration) {
+	return rebootNotReadyTimeout, rebootReadyAgainTimeout
+}
+
+// kubeletStopDisruptor kills only the kubelet, leaving the kernel and any
+// already-running containers untouched. It exercises node recovery without
+// a kernel restart in the mix.
+type kubeletStopDisruptor struct{}
+
+func (*kubeletStopDisruptor) Name() string { return "kubelet outage" }
+
+func (*kubeletStopDisruptor) Disrupt(exec NodeExecutor, c *client.Client, node *api.Node) error {
+	return exec.Execute(c, node, "sudo systemctl stop kubelet")
+}
+
+func (*kubeletStopDisruptor) ExpectedRecoveryWindow() (time.Duration, time.Duration) {
+	return rebootNotReadyTimeout, rebootReadyAgainTimeout
+}
+
+// networkPartitionDisruptor drops all inbound traffic to the kubelet's
+// apiserver-facing port, simulating a network partition between the node
+// and the control plane rather than a crash of anything running on it.
+type networkPartitionDisruptor struct{}
+
+func (*networkPartitionDisruptor) Name() string { return "network partition" }
+
+func (*networkPartitionDisruptor) Disrupt(exec NodeExecutor, c *client.Client, node *api.Node) error {
+	return exec.Execute(c, node, "sudo iptables -A INPUT -p tcp --dport 10250 -j DROP")
+}
+
+func (*networkPartitionDisruptor) ExpectedRecoveryWindow() (time.Duration, time.Duration) {
+	return rebootNotReadyTimeout, rebootReadyAgainTimeout
+}
+
+// kernelPanicDisruptor triggers an unclean shutdown via sysrq, the harshest
+// disruption in the suite: there is no opportunity for a graceful kubelet
+// or container shutdown at all.
+type kernelPanicDisruptor struct{}
+
+func (*kernelPanicDisruptor) Name() string { return "kernel panic" }
+
+func (*kernelPanicDisruptor) Disrupt(exec NodeExecutor, c *client.Client, node *api.Node) error {
+	// Writing to sysrq-trigger resets the node before the command's own
+	// transport (an SSH session, an nsenter'd pod) can report a clean exit,
+	// so exec.Execute returning an error here is the expected outcome, not
+	// a sign the disruption failed to fire. Unlike the other Disruptors, we
+	// don't propagate it to the caller.
+	exec.Execute(c, node, "echo b | sudo tee /proc/sysrq-trigger")
+	return nil
+}
+
+func (*kernelPanicDisruptor) ExpectedRecoveryWindow() (time.Duration, time.Duration) {
+	// An unclean shutdown gives the node less time to notice and report
+	// NotReady, but more time to come back up cleanly afterwards.
+	return kernelPanicNotReadyTimeout, rebootReadyAgainTimeout + poll*6
+}
+
+// NodeExecutor knows how to run a shell command on a node, abstracting over
+// how that command actually reaches it. Disruptors are written against this
+// interface rather than against SSH directly, so the Reboot suite isn't
+// limited to providers the e2e runner happens to have node SSH keys for.
+type NodeExecutor interface {
+	// Execute runs cmd on node and returns an error if it could not be run
+	// or exited non-zero. Commands that tear down their own transport
+	// before it can report a clean exit (a kernel panic, a hard reboot)
+	// may surface as an error here. kernelPanicDisruptor ignores that
+	// error; the other Disruptors return it to their caller.
+	Execute(c *client.Client, node *api.Node, cmd string) error
+}
+
+// executorForProvider returns the NodeExecutor used to run disruption
+// commands on nodes for provider. Providers without a dedicated transport
+// fall back to podExecNodeExecutor, which needs nothing from the provider
+// beyond the ability to schedule a privileged pod onto the node.
+func executorForProvider(provider string) NodeExecutor {
+	switch provider {
+	case "gce", "gke":
+		return &sshNodeExecutor{provider: provider}
+	case "aws":
+		return &awsBastionNodeExecutor{}
+	case "vagrant", "vsphere":
+		return &vagrantNodeExecutor{}
+	default:
+		return &podExecNodeExecutor{}
+	}
+}
+
+// sshNodeExecutor runs commands over SSH to the node's external IP. It's the
+// transport for providers where the e2e runner has direct SSH access to
+// nodes (GCE, GKE) -- the original (and only) transport this suite had.
+type sshNodeExecutor struct {
+	provider string
+}
+
+func (e *sshNodeExecutor) Execute(c *client.Client, node *api.Node, cmd string) error {
+	host, err := nodeExternalSSHHost(node)
+	if err != nil {
+		return err
+	}
+	return sshAndCheck(cmd, host, e.provider)
+}
+
+// nodeExternalSSHHost returns node's external IP formatted as a "host:22"
+// SSH target.
+func nodeExternalSSHHost(node *api.Node) (string, error) {
+	for _, a := range node.Status.Addresses {
+		if a.Type == api.NodeExternalIP {
+			return a.Address + ":22", nil
+		}
+	}
+	return "", fmt.Errorf("couldn't find external IP address for node %s", node.ObjectMeta.Name)
+}
+
+// awsBastionNodeExecutor reaches nodes over SSH through a bastion host: AWS
+// e2e clusters typically only expose nodes on their internal IPs, so it
+// hops from the configured bastion to the node's internal IP for a second
+// SSH invocation rather than connecting to the node directly.
+type awsBastionNodeExecutor struct{}
+
+func (*awsBastionNodeExecutor) Execute(c *client.Client, node *api.Node, cmd string) error {
+	bastion := testContext.CloudConfig.BastionHost
+	if bastion == "" {
+		return fmt.Errorf("no bastion host configured; set --bastion-host to reboot-test nodes on aws")
+	}
+	nodeHost := ""
+	for _, a := range node.Status.Addresses {
+		if a.Type == api.NodeInternalIP {
+			nodeHost = a.Address
+			break
+		}
+	}
+	if nodeHost == "" {
+		return fmt.Errorf("couldn't find internal IP address for node %s", node.ObjectMeta.Name)
+	}
+	hop := fmt.Sprintf("ssh -o StrictHostKeyChecking=no %s %s", nodeHost, quoteForShell(cmd))
+	return sshAndCheck(hop, bastion+":22", "aws")
+}
+
+// vagrantNodeExecutor runs cmd on the node's local Vagrant VM via `vagrant
+// ssh`, for the vSphere/vagrant provider where the e2e runner and the
+// cluster share a host and there's no SSH-key-based path to the node.
+type vagrantNodeExecutor struct{}
+
+func (*vagrantNodeExecutor) Execute(c *client.Client, node *api.Node, cmd string) error {
+	out, err := exec.Command("vagrant", "ssh", node.ObjectMeta.Name, "-c", cmd).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("vagrant ssh %s -c %s failed: %v: %s", node.ObjectMeta.Name, quoteForShell(cmd), err, out)
+	}
+	return nil
+}
+
+// podExecNodeExecutor runs cmd by scheduling a privileged, hostPID pod
+// directly onto the node and nsenter-ing into PID 1's namespaces. It needs
+// no SSH access at all, making it the fallback for any provider that can
+// schedule privileged pods.
+type podExecNodeExecutor struct{}
+
+func (*podExecNodeExecutor) Execute(c *client.Client, node *api.Node, cmd string) error {
+	privileged := true
+	podName := "reboot-exec-" + string(util.NewUUID())
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: podName, Namespace: api.NamespaceSystem},
+		Spec: api.PodSpec{
+			NodeName:      node.ObjectMeta.Name,
+			HostPID:       true,
+			RestartPolicy: api.RestartPolicyNever,
+			Containers: []api.Container{
+				{
+					Name:  "exec",
+					Image: "gcr.io/google_containers/node-exec:e2e",
+					Command: []string{
+						"nsenter", "--target", "1", "--mount", "--uts", "--ipc", "--net", "--pid",
+						"--", "sh", "-c", cmd,
+					},
+					SecurityContext: &api.SecurityContext{
+						Privileged: &privileged,
+					},
+				},
+			},
+		},
+	}
+	if _, err := c.Pods(api.NamespaceSystem).Create(pod); err != nil {
+		return fmt.Errorf("failed to schedule exec pod onto %s: %v", node.ObjectMeta.Name, err)
+	}
+	defer c.Pods(api.NamespaceSystem).Delete(podName, nil)
+
+	return waitForPodExecResult(c, podName)
+}
+
+// podExecTimeout bounds how long waitForPodExecResult waits for the exec
+// pod to reach a terminal phase. A command that takes the node down with it
+// (e.g. a kernel panic) never gets there; a timeout surfaces as an error
+// here exactly like a dropped SSH connection does for sshNodeExecutor, and
+// Disruptors that already tolerate that (see kernelPanicDisruptor) keep
+// doing so.
+const podExecTimeout = 2 * time.Minute
+
+// waitForPodExecResult waits for the single-container exec pod podName (in
+// api.NamespaceSystem) to finish, and returns an error if it didn't exit
+// cleanly -- reaching Running is not enough, since the command run inside
+// it (via nsenter) may still fail after the container itself starts fine.
+func waitForPodExecResult(c *client.Client, podName string) error {
+	var pod *api.Pod
+	err := wait.Poll(poll, podExecTimeout, func() (bool, error) {
+		p, err := c.Pods(api.NamespaceSystem).Get(podName)
+		if err != nil {
+			return false, nil
+		}
+		pod = p
+		return pod.Status.Phase == api.PodSucceeded || pod.Status.Phase == api.PodFailed, nil
+	})
+	if err != nil {
+		return fmt.Errorf("exec pod %s never reached a terminal phase: %v", podName, err)
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if term := cs.State.Terminated; term != nil && term.ExitCode != 0 {
+			return fmt.Errorf("exec pod %s command exited %d: %s", podName, term.ExitCode, term.Message)
+		}
+	}
+	return nil
+}
+
+// quoteForShell wraps s in single quotes for embedding in a shell command,
+// escaping any single quotes it contains.
+func quoteForShell(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// sshAndCheck runs cmd on host via SSH and fails loudly if it doesn't exit
+// cleanly. A command that itself kills the SSH session (e.g. a kernel
+// panic) can surface as an error here from the connection tearing down;
+// kernelPanicDisruptor, which reaches this helper through sshNodeExecutor,
+// ignores that error.
+func sshAndCheck(cmd, host, provider string) error {
+	if _, _, code, err := SSH(cmd, host, provider); code != 0 || err != nil {
+		return fmt.Errorf("expected 0 exit code and nil error when running %s on %s, got %d and %v",
+			cmd, host, code, err)
+	}
+	return nil
+}
+
+// rebootNode takes node name through the following steps using c:
 //  - ensures the node is ready
 //  - ensures all pods on the node are running and ready
-//  - reboots the node
+//  - disrupts the node via d, run through exec
 //  - ensures the node reaches some non-ready state
 //  - ensures the node becomes ready again
 //  - ensures all pods on the node become running and ready again
 //
 // It returns true through result only if all of the steps pass; at the first
 // failed step, it will return false through result and not run the rest.
-func rebootNode(c *client.Client, provider, name string, result chan bool) {
+func rebootNode(c *client.Client, exec NodeExecutor, name string, d Disruptor, result chan bool) {
 	// Get the node initially.
 	Logf("Getting %s", name)
 	node, err := c.Nodes().Get(name)
@@ -144,37 +559,24 @@ func rebootNode(c *client.Client, provider, name string, result chan bool) {
 		return
 	}
 
-	// Reboot the node.
-	Logf("Getting external IP address for %s", name)
-	host := ""
-	for _, a := range node.Status.Addresses {
-		if a.Type == api.NodeExternalIP {
-			host = a.Address + ":22"
-			break
-		}
-	}
-	if host == "" {
-		Logf("Couldn't find external IP address for node %s", name)
-		result <- false
-		return
-	}
-	Logf("Calling reboot on %s", name)
-	rebootCmd := "sudo reboot"
-	if _, _, code, err := SSH(rebootCmd, host, provider); code != 0 || err != nil {
-		Failf("Expected 0 exit code and nil error when running %s on %s, got %d and %v",
-			rebootCmd, node, code, err)
+	// Disrupt the node.
+	Logf("Disrupting node %s via %s", name, d.Name())
+	if err := d.Disrupt(exec, c, node); err != nil {
+		Failf("Error disrupting node %s via %s: %v", name, d.Name(), err)
 		result <- false
 		return
 	}
 
+	notReadyTimeout, readyAgainTimeout := d.ExpectedRecoveryWindow()
+
 	// Wait for some kind of "not ready" status.
-	if !waitForNodeToBeNotReady(c, name, rebootNotReadyTimeout) {
+	if !waitForNodeToBeNotReady(c, name, notReadyTimeout) {
 		result <- false
 		return
 	}
 
 	// Wait for some kind of "ready" status.
-	if !waitForNodeToBeReady(c, name, rebootReadyAgainTimeout) {
+	if !waitForNodeToBeReady(c, name, readyAgainTimeout) {
 		result <- false
 		return
 	}
@@ -186,7 +588,7 @@ func rebootNode(c *client.Client, provider, name string, result chan bool) {
 		return
 	}
 
-	Logf("Reboot successful on node %s", name)
+	Logf("Reboot successful on node %s via %s", name, d.Name())
 	result <- true
 }
 
@@ -237,6 +639,39 @@ func checkPodsRunning(c *client.Client, podNames []string, timeout time.Duration
 	return success
 }
 
+// waitForRCPodsRunning returns whether at least atLeast of the RC rcName's
+// (in ns) pods are Running within timeout. If excludeNode is non-empty, pods
+// scheduled on that node don't count, so callers can assert that pods were
+// rescheduled onto other nodes rather than merely restarted in place.
+func waitForRCPodsRunning(c *client.Client, ns, rcName, excludeNode string, atLeast int, timeout time.Duration) bool {
+	rc, err := c.ReplicationControllers(ns).Get(rcName)
+	if err != nil {
+		Logf("Error getting RC %s: %v", rcName, err)
+		return false
+	}
+	selector := labels.Set(rc.Spec.Selector).AsSelector()
+	Logf("Waiting up to %v for at least %d pods of RC %s to be running (excluding node %q)",
+		timeout, atLeast, rcName, excludeNode)
+	err = wait.Poll(poll, timeout, func() (bool, error) {
+		pods, err := c.Pods(ns).List(selector, fields.Everything())
+		if err != nil {
+			Logf("Error listing pods for RC %s: %v", rcName, err)
+			return false, nil
+		}
+		running := 0
+		for _, p := range pods.Items {
+			if excludeNode != "" && p.Spec.Host == excludeNode {
+				continue
+			}
+			if p.Status.Phase == api.PodRunning {
+				running++
+			}
+		}
+		return running >= atLeast, nil
+	})
+	return err == nil
+}
+
 // waitForNodeToBeReady returns whether node name is ready within timeout.
 func waitForNodeToBeReady(c *client.Client, name string, timeout time.Duration) bool {
 	return waitForNodeToBe(c, name, true, timeout)
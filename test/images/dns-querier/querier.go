@@ -0,0 +1,219 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command dns-querier performs a batch of DNS lookups described by a JSON
+// queries file and writes a JSON report of the results -- including the
+// answer set, whether the lookup succeeded, and its latency -- to a results
+// file.
+//
+// It exists so the DNS e2e test (test/e2e/dns.go) can drive the lookups it
+// cares about without shelling out to `dig` once per name/protocol and
+// scraping the result through one apiserver proxy GET per file, which
+// doesn't scale once the suite needs hundreds of names and loses the
+// latency data entirely.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// query is one lookup the e2e test wants performed. Type selects which
+// lookup is done ("A", "SRV", or "PTR"); Proto "tcp" forces "A" lookups
+// over TCP, and any other value (e.g. "udp") uses the system resolver,
+// which picks its own transport -- SRV and PTR lookups ignore it,
+// since net.LookupSRV/net.LookupAddr don't expose a way to pin the
+// transport and nothing currently needs them to.
+type query struct {
+	Category string `json:"category"`
+	Proto    string `json:"proto"`
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+}
+
+// result is the outcome of a single query.
+type result struct {
+	Category  string   `json:"category"`
+	Proto     string   `json:"proto"`
+	Key       string   `json:"key"`
+	OK        bool     `json:"ok"`
+	Error     string   `json:"error,omitempty"`
+	Answers   []string `json:"answers,omitempty"`
+	LatencyMs float64  `json:"latencyMs"`
+}
+
+// report is the top-level document written to the results file.
+type report struct {
+	Results []result `json:"results"`
+}
+
+func main() {
+	queriesFile := flag.String("queries", "/queries.json", "path to a JSON file listing the queries to perform")
+	resultsFile := flag.String("out", "/results/summary.json", "path to write the JSON results report to")
+	flag.Parse()
+
+	raw, err := ioutil.ReadFile(*queriesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dns-querier: reading queries file %s: %v\n", *queriesFile, err)
+		os.Exit(1)
+	}
+	var queries []query
+	if err := json.Unmarshal(raw, &queries); err != nil {
+		fmt.Fprintf(os.Stderr, "dns-querier: parsing queries file %s: %v\n", *queriesFile, err)
+		os.Exit(1)
+	}
+
+	rep := report{Results: make([]result, 0, len(queries))}
+	for _, q := range queries {
+		rep.Results = append(rep.Results, runQuery(q))
+	}
+
+	out, err := json.Marshal(rep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dns-querier: marshaling report: %v\n", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(*resultsFile, out, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "dns-querier: writing results file %s: %v\n", *resultsFile, err)
+		os.Exit(1)
+	}
+}
+
+// runQuery performs q and times how long it took, regardless of whether it
+// succeeded.
+func runQuery(q query) result {
+	start := time.Now()
+	var answers []string
+	var err error
+
+	switch {
+	case q.Type == "A" && q.Proto == "tcp":
+		var ancount int
+		ancount, err = dnsQueryOverTCP(q.Name)
+		if err == nil && ancount > 0 {
+			answers = []string{fmt.Sprintf("%d answer(s) over tcp", ancount)}
+		}
+	case q.Type == "SRV":
+		var srvs []*net.SRV
+		_, srvs, err = net.LookupSRV("", "", q.Name)
+		for _, s := range srvs {
+			answers = append(answers, fmt.Sprintf("%s:%d", s.Target, s.Port))
+		}
+	case q.Type == "PTR":
+		answers, err = net.LookupAddr(q.Name)
+	default: // "A" over the system resolver, which net.LookupHost doesn't
+		// let us pin to a transport -- it's effectively the "udp" case.
+		answers, err = net.LookupHost(q.Name)
+	}
+
+	res := result{
+		Category:  q.Category,
+		Proto:     q.Proto,
+		Key:       q.Key,
+		Answers:   answers,
+		LatencyMs: time.Since(start).Seconds() * 1000,
+	}
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.OK = len(answers) > 0
+	}
+	return res
+}
+
+// dnsQueryOverTCP performs a single A-record query against the system
+// resolver over a plain TCP connection and returns the number of answers in
+// the response. name is sent exactly as given: unlike the `dig +tcp +search`
+// this binary replaced, no search domains from /etc/resolv.conf are applied.
+// net.LookupHost always lets the resolver library pick the transport, so
+// there's no way to get this same guarantee through it.
+func dnsQueryOverTCP(name string) (int, error) {
+	server, err := systemResolverAddr()
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := net.DialTimeout("tcp", server, 5*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	msg := encodeDNSQuery(name)
+	framed := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(framed, uint16(len(msg)))
+	copy(framed[2:], msg)
+	if _, err := conn.Write(framed); err != nil {
+		return 0, err
+	}
+
+	var respLenBuf [2]byte
+	if _, err := io.ReadFull(conn, respLenBuf[:]); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(respLenBuf[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return 0, err
+	}
+	if len(resp) < 12 {
+		return 0, fmt.Errorf("short DNS response (%d bytes)", len(resp))
+	}
+	return int(binary.BigEndian.Uint16(resp[6:8])), nil // ANCOUNT
+}
+
+// systemResolverAddr returns the first nameserver in /etc/resolv.conf as a
+// "host:53" address.
+func systemResolverAddr() (string, error) {
+	raw, err := ioutil.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+	return "", fmt.Errorf("no nameserver found in /etc/resolv.conf")
+}
+
+// encodeDNSQuery builds a minimal, single-question DNS query message (with
+// no length prefix) asking for the A record of name.
+func encodeDNSQuery(name string) []byte {
+	var buf bytes.Buffer
+	// Header: arbitrary ID, standard recursive query, 1 question, 0
+	// answer/authority/additional records.
+	buf.Write([]byte{0xe2, 0xe2, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		buf.WriteByte(byte(len(label)))
+		buf.WriteString(label)
+	}
+	buf.WriteByte(0)              // root label
+	buf.Write([]byte{0x00, 0x01}) // QTYPE A
+	buf.Write([]byte{0x00, 0x01}) // QCLASS IN
+	return buf.Bytes()
+}